@@ -0,0 +1,126 @@
+package t1k
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync/atomic"
+)
+
+// ErrNoHealthyBackend is returned by a Balancer when none of the given
+// backends are eligible to serve a call.
+var ErrNoHealthyBackend = errors.New("t1k: no healthy backend available")
+
+// Backend pairs a pooled Server with the endpoint address it talks to, so
+// a Balancer implemented outside this package can still reason about
+// addresses and load without reaching into Server internals.
+type Backend struct {
+	addr string
+	srv  *Server
+}
+
+// Addr is the endpoint address this Backend dials.
+func (b *Backend) Addr() string { return b.addr }
+
+// InUse is the number of connections currently checked out of this
+// Backend's pool, as reported by its underlying Server.
+func (b *Backend) InUse() int { return b.srv.InUse() }
+
+// Balancer picks one of a set of healthy backends to serve the next call.
+// Implementations must be safe for concurrent use.
+type Balancer interface {
+	// Pick selects a Backend from healthy. clientIP is used by balancers
+	// that want session affinity and may be empty.
+	Pick(healthy []*Backend, clientIP string) (*Backend, error)
+}
+
+// RoundRobinBalancer cycles through the healthy backends in order.
+type RoundRobinBalancer struct {
+	next uint64
+}
+
+func NewRoundRobinBalancer() *RoundRobinBalancer {
+	return &RoundRobinBalancer{}
+}
+
+func (b *RoundRobinBalancer) Pick(healthy []*Backend, _ string) (*Backend, error) {
+	if len(healthy) == 0 {
+		return nil, ErrNoHealthyBackend
+	}
+	i := atomic.AddUint64(&b.next, 1)
+	return healthy[int(i)%len(healthy)], nil
+}
+
+// LeastLoadedBalancer picks the healthy Backend with the fewest
+// connections currently checked out of its pool.
+type LeastLoadedBalancer struct{}
+
+func NewLeastLoadedBalancer() *LeastLoadedBalancer {
+	return &LeastLoadedBalancer{}
+}
+
+func (b *LeastLoadedBalancer) Pick(healthy []*Backend, _ string) (*Backend, error) {
+	if len(healthy) == 0 {
+		return nil, ErrNoHealthyBackend
+	}
+	best := healthy[0]
+	bestInUse := best.srv.InUse()
+	for _, be := range healthy[1:] {
+		if inUse := be.srv.InUse(); inUse < bestInUse {
+			best, bestInUse = be, inUse
+		}
+	}
+	return best, nil
+}
+
+// ConsistentHashBalancer hashes the client IP onto a ring of virtual nodes
+// so that calls from the same client stick to the same Backend across
+// calls, as long as the Backend stays healthy.
+type ConsistentHashBalancer struct {
+	vnodes int
+}
+
+// NewConsistentHashBalancer builds a ConsistentHashBalancer with vnodes
+// virtual nodes per Backend; more virtual nodes spread load more evenly
+// at the cost of a larger ring to search. 0 uses a sensible default.
+func NewConsistentHashBalancer(vnodes int) *ConsistentHashBalancer {
+	if vnodes <= 0 {
+		vnodes = 100
+	}
+	return &ConsistentHashBalancer{vnodes: vnodes}
+}
+
+func (b *ConsistentHashBalancer) Pick(healthy []*Backend, clientIP string) (*Backend, error) {
+	if len(healthy) == 0 {
+		return nil, ErrNoHealthyBackend
+	}
+	if clientIP == "" {
+		return healthy[0], nil
+	}
+
+	type ringEntry struct {
+		hash uint32
+		be   *Backend
+	}
+	ring := make([]ringEntry, 0, len(healthy)*b.vnodes)
+	for _, be := range healthy {
+		for i := 0; i < b.vnodes; i++ {
+			ring = append(ring, ringEntry{hash: hashKey(be.addr, i), be: be})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+
+	key := hashKey(clientIP, 0)
+	idx := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= key })
+	if idx == len(ring) {
+		idx = 0
+	}
+	return ring[idx].be, nil
+}
+
+func hashKey(s string, vnode int) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	h.Write([]byte{byte(vnode), byte(vnode >> 8)})
+	return h.Sum32()
+}
@@ -0,0 +1,263 @@
+package t1k
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chaitin/t1k-go/detection"
+)
+
+// Options configures how Cluster dials the Server backing each endpoint.
+type Options struct {
+	PoolSize int
+	// Timeout, if non-zero, bounds each dial via net.DialTimeout.
+	Timeout time.Duration
+}
+
+func (o Options) poolSize() int {
+	if o.PoolSize <= 0 {
+		return DEFAULT_POOL_SIZE
+	}
+	return o.PoolSize
+}
+
+func (o Options) newServer(addr string) (*Server, error) {
+	if o.Timeout > 0 {
+		return NewWithPoolSizeWithTimeout(addr, o.poolSize(), o.Timeout)
+	}
+	return NewWithPoolSize(addr, o.poolSize())
+}
+
+// Cluster load-balances and fails over Detect* calls across the Servers of
+// several T1K detector endpoints, mirroring how service-discovery-driven
+// clients manage one pool per node. Unhealthy backends are left out of the
+// set handed to the Balancer and automatically rejoin once their
+// HealthCheckService reports them healthy again.
+type Cluster struct {
+	mu       sync.RWMutex
+	backends map[string]*Backend
+	opts     Options
+	balancer Balancer
+}
+
+// NewMultiServer dials a Server per address in addrs and returns a Cluster
+// that dispatches Detect* calls across them via balancer.
+func NewMultiServer(addrs []string, opts Options, balancer Balancer) (*Cluster, error) {
+	c := &Cluster{
+		backends: make(map[string]*Backend, len(addrs)),
+		opts:     opts,
+		balancer: balancer,
+	}
+
+	for _, addr := range addrs {
+		be, err := c.dial(addr)
+		if err != nil {
+			// Nothing has served traffic yet, so there is nothing to
+			// drain: close the backends dialed so far immediately.
+			for _, be := range c.backends {
+				be.srv.Close()
+			}
+			return nil, err
+		}
+		c.backends[addr] = be
+	}
+
+	return c, nil
+}
+
+func (c *Cluster) dial(addr string) (*Backend, error) {
+	srv, err := c.opts.newServer(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Backend{addr: addr, srv: srv}, nil
+}
+
+// closeAll drains every backend concurrently via Shutdown(ctx), so one
+// slow backend does not hold up draining the rest.
+func (c *Cluster) closeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, be := range c.backends {
+		wg.Add(1)
+		go func(be *Backend) {
+			defer wg.Done()
+			be.srv.Shutdown(ctx)
+		}(be)
+	}
+	wg.Wait()
+}
+
+// UpdateEndpoints diffs addrs against the current endpoint set: it dials a
+// new Backend for each address not already present, and gracefully drains
+// (via Server.Shutdown, bounded by ctx) every Backend whose address is no
+// longer in addrs.
+func (c *Cluster) UpdateEndpoints(ctx context.Context, addrs []string) error {
+	wanted := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		wanted[addr] = struct{}{}
+	}
+
+	c.mu.Lock()
+	removed := make(map[string]*Backend)
+	for addr, be := range c.backends {
+		if _, ok := wanted[addr]; !ok {
+			removed[addr] = be
+			delete(c.backends, addr)
+		}
+	}
+
+	var dialErr error
+	for addr := range wanted {
+		if _, ok := c.backends[addr]; ok {
+			continue
+		}
+		be, err := c.dial(addr)
+		if err != nil {
+			dialErr = err
+			break
+		}
+		c.backends[addr] = be
+	}
+	c.mu.Unlock()
+
+	// Drain the removed backends outside c.mu so pick() keeps serving from
+	// the already-updated map instead of blocking every in-flight Detect*
+	// call for the whole drain window.
+	var wg sync.WaitGroup
+	for _, be := range removed {
+		wg.Add(1)
+		go func(be *Backend) {
+			defer wg.Done()
+			be.srv.Shutdown(ctx)
+		}(be)
+	}
+	wg.Wait()
+
+	return dialErr
+}
+
+// Close gracefully drains every Backend Server in the cluster, bounded by
+// ctx.
+func (c *Cluster) Close(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeAll(ctx)
+}
+
+// maxFailoverAttempts bounds how many backends a single Detect* call will
+// try before giving up, so a cluster with many simultaneously-failing
+// backends fails fast instead of exhausting the whole set on every call.
+const maxFailoverAttempts = 3
+
+func (c *Cluster) pick(clientIP string, exclude map[string]struct{}) (*Backend, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	healthy := make([]*Backend, 0, len(c.backends))
+	for addr, be := range c.backends {
+		if _, skip := exclude[addr]; skip {
+			continue
+		}
+		if be.srv.IsHealth() {
+			healthy = append(healthy, be)
+		}
+	}
+	// map iteration order is random; give the Balancer a stable order so
+	// order-based balancers such as RoundRobinBalancer really cycle.
+	sort.Slice(healthy, func(i, j int) bool { return healthy[i].addr < healthy[j].addr })
+	return c.balancer.Pick(healthy, clientIP)
+}
+
+// withFailover picks a Backend via the balancer and runs call against it,
+// retrying against a different healthy Backend (excluding any that have
+// already failed this call), trying at most maxFailoverAttempts Backends
+// in all. It gives up
+// and returns the last error once no further healthy Backend is available
+// or the attempt budget is spent.
+func (c *Cluster) withFailover(clientIP string, call func(*Backend) error) error {
+	exclude := make(map[string]struct{})
+	var lastErr error
+	for attempt := 0; attempt < maxFailoverAttempts; attempt++ {
+		be, err := c.pick(clientIP, exclude)
+		if err != nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			return err
+		}
+		if lastErr = call(be); lastErr == nil {
+			return nil
+		}
+		exclude[be.addr] = struct{}{}
+	}
+	return lastErr
+}
+
+// clientIPFromRequest extracts the caller's address from req.RemoteAddr
+// for balancers that want session affinity; it returns "" if unavailable.
+func clientIPFromRequest(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func (c *Cluster) DetectRequestInCtx(dc *detection.DetectionContext) (*detection.Result, error) {
+	var res *detection.Result
+	err := c.withFailover("", func(be *Backend) error {
+		r, err := be.srv.DetectRequestInCtx(dc)
+		res = r
+		return err
+	})
+	return res, err
+}
+
+func (c *Cluster) DetectResponseInCtx(dc *detection.DetectionContext) (*detection.Result, error) {
+	var res *detection.Result
+	err := c.withFailover("", func(be *Backend) error {
+		r, err := be.srv.DetectResponseInCtx(dc)
+		res = r
+		return err
+	})
+	return res, err
+}
+
+func (c *Cluster) Detect(dc *detection.DetectionContext) (*detection.Result, *detection.Result, error) {
+	var reqRes, respRes *detection.Result
+	err := c.withFailover("", func(be *Backend) error {
+		r1, r2, err := be.srv.Detect(dc)
+		reqRes, respRes = r1, r2
+		return err
+	})
+	return reqRes, respRes, err
+}
+
+func (c *Cluster) DetectRequest(req detection.Request) (*detection.Result, error) {
+	var res *detection.Result
+	err := c.withFailover("", func(be *Backend) error {
+		r, err := be.srv.DetectRequest(req)
+		res = r
+		return err
+	})
+	return res, err
+}
+
+// DetectHttpRequest picks a Backend using the balancer, deriving the
+// client IP from req.RemoteAddr for balancers that key on it (e.g.
+// ConsistentHashBalancer), and fails over to another healthy Backend (up
+// to maxFailoverAttempts) if the call itself errors.
+func (c *Cluster) DetectHttpRequest(req *http.Request) (*detection.Result, error) {
+	clientIP := clientIPFromRequest(req)
+	var res *detection.Result
+	err := c.withFailover(clientIP, func(be *Backend) error {
+		r, err := be.srv.DetectHttpRequest(req)
+		res = r
+		return err
+	})
+	return res, err
+}
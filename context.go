@@ -0,0 +1,125 @@
+package t1k
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/chaitin/t1k-go/detection"
+	"github.com/chaitin/t1k-go/misc"
+)
+
+// getConnContext is GetConn, but it gives up waiting for a pooled
+// connection once ctx is done, and returns the raw socket alongside the
+// wrapper so callers can derive a deadline from ctx for the I/O that
+// follows.
+func (s *Server) getConnContext(ctx context.Context) (*conn, net.Conn, error) {
+	type result struct {
+		c    *conn
+		sock net.Conn
+		err  error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		c, err := s.newConn()
+		if err != nil {
+			ch <- result{err: err}
+			return
+		}
+		sock, _ := s.socks.Load(c)
+		ch <- result{c: c, sock: sock.(net.Conn)}
+	}()
+
+	select {
+	case <-ctx.Done():
+		// the dial may still land after we give up on it; when it does,
+		// mark it failing so it is reconnected rather than reused with
+		// whatever is left on the wire.
+		go func() {
+			if r := <-ch; r.c != nil {
+				r.c.failing = true
+				s.PutConn(r.c)
+			}
+		}()
+		return nil, nil, ctx.Err()
+	case r := <-ch:
+		if r.err != nil {
+			return nil, nil, r.err
+		}
+		if err := s.reconnectIfFailing(r.c); err != nil {
+			return nil, nil, err
+		}
+		if dl, ok := ctx.Deadline(); ok {
+			r.sock.SetDeadline(dl)
+		}
+		return r.c, r.sock, nil
+	}
+}
+
+// putConnContext returns c to the pool, resetting any deadline set for
+// this call and marking it failing if ctx was cancelled mid-flight so the
+// socket is reconnected instead of reused.
+func (s *Server) putConnContext(ctx context.Context, c *conn, sock net.Conn) {
+	sock.SetDeadline(time.Time{})
+	if ctx.Err() != nil {
+		c.failing = true
+	}
+	s.PutConn(c)
+}
+
+// DetectRequestContext is DetectRequest with a context: it honors ctx.Done
+// while waiting for a pooled connection, and bounds the socket I/O of the
+// call itself by ctx's deadline. Cancelling a ctx that has no deadline
+// does not interrupt I/O already in progress.
+func (s *Server) DetectRequestContext(ctx context.Context, req detection.Request) (*detection.Result, error) {
+	defer s.observe("DetectRequest", time.Now())
+	c, sock, err := s.getConnContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.putConnContext(ctx, c, sock)
+	return c.DetectRequest(req)
+}
+
+// DetectResponseContext is DetectResponseInCtx with a context.
+func (s *Server) DetectResponseContext(ctx context.Context, dc *detection.DetectionContext) (*detection.Result, error) {
+	defer s.observe("DetectResponseInCtx", time.Now())
+	c, sock, err := s.getConnContext(ctx)
+	if err != nil {
+		return nil, misc.ErrorWrap(err, "")
+	}
+	defer s.putConnContext(ctx, c, sock)
+	return c.DetectResponseInCtx(dc)
+}
+
+// DetectContext is Detect with a context.
+func (s *Server) DetectContext(ctx context.Context, dc *detection.DetectionContext) (*detection.Result, *detection.Result, error) {
+	defer s.observe("Detect", time.Now())
+	c, sock, err := s.getConnContext(ctx)
+	if err != nil {
+		return nil, nil, misc.ErrorWrap(err, "")
+	}
+	defer s.putConnContext(ctx, c, sock)
+	return c.Detect(dc)
+}
+
+// DetectHttpRequestContext is DetectHttpRequest with a context.
+func (s *Server) DetectHttpRequestContext(ctx context.Context, req *http.Request) (*detection.Result, error) {
+	defer s.observe("DetectHttpRequest", time.Now())
+	c, sock, err := s.getConnContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.putConnContext(ctx, c, sock)
+	return c.DetectHttpRequest(req)
+}
+
+// observe reports a completed Detect* call to Metrics, keyed by method
+// name so per-call latency can be graphed alongside overall throughput.
+func (s *Server) observe(method string, start time.Time) {
+	m := s.currentMetrics()
+	m.IncDetections(method)
+	m.ObserveLatency(method, time.Since(start))
+}
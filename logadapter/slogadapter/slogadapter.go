@@ -0,0 +1,19 @@
+// Package slogadapter adapts log/slog to t1k.Logger.
+package slogadapter
+
+import "log/slog"
+
+// Logger implements t1k.Logger on top of a *slog.Logger.
+type Logger struct {
+	l *slog.Logger
+}
+
+// New wraps l as a t1k.Logger.
+func New(l *slog.Logger) *Logger {
+	return &Logger{l: l}
+}
+
+func (a *Logger) Debug(msg string, kv ...interface{}) { a.l.Debug(msg, kv...) }
+func (a *Logger) Info(msg string, kv ...interface{})  { a.l.Info(msg, kv...) }
+func (a *Logger) Warn(msg string, kv ...interface{})  { a.l.Warn(msg, kv...) }
+func (a *Logger) Error(msg string, kv ...interface{}) { a.l.Error(msg, kv...) }
@@ -0,0 +1,19 @@
+// Package zapadapter adapts go.uber.org/zap to t1k.Logger.
+package zapadapter
+
+import "go.uber.org/zap"
+
+// Logger implements t1k.Logger on top of a *zap.SugaredLogger.
+type Logger struct {
+	l *zap.SugaredLogger
+}
+
+// New wraps l as a t1k.Logger.
+func New(l *zap.SugaredLogger) *Logger {
+	return &Logger{l: l}
+}
+
+func (a *Logger) Debug(msg string, kv ...interface{}) { a.l.Debugw(msg, kv...) }
+func (a *Logger) Info(msg string, kv ...interface{})  { a.l.Infow(msg, kv...) }
+func (a *Logger) Warn(msg string, kv ...interface{})  { a.l.Warnw(msg, kv...) }
+func (a *Logger) Error(msg string, kv ...interface{}) { a.l.Errorw(msg, kv...) }
@@ -0,0 +1,44 @@
+package t1k
+
+import (
+	"log"
+)
+
+// Logger is the logging interface Server uses internally. It matches the
+// common structured-logging shape (leveled calls with key-value pairs) so
+// that adapters for log/slog, zap, etc. can be dropped in without pulling
+// those dependencies into this module; see the logadapter subpackages.
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// stdLogger adapts a stdlib *log.Logger to the Logger interface. It is the
+// zero-configuration default so existing callers see no behavior change.
+type stdLogger struct {
+	l *log.Logger
+}
+
+func newStdLogger(l *log.Logger) *stdLogger {
+	return &stdLogger{l: l}
+}
+
+func (s *stdLogger) log(level, msg string, kv ...interface{}) {
+	args := append([]interface{}{level, msg}, kv...)
+	s.l.Println(args...)
+}
+
+func (s *stdLogger) Debug(msg string, kv ...interface{}) { s.log("DEBUG", msg, kv...) }
+func (s *stdLogger) Info(msg string, kv ...interface{})  { s.log("INFO", msg, kv...) }
+func (s *stdLogger) Warn(msg string, kv ...interface{})  { s.log("WARN", msg, kv...) }
+func (s *stdLogger) Error(msg string, kv ...interface{}) { s.log("ERROR", msg, kv...) }
+
+// UpdateLogger swaps the Logger used for internal diagnostics, e.g. to
+// plug this module into an existing observability stack.
+func (s *Server) UpdateLogger(logger Logger) {
+	s.configLock.Lock()
+	defer s.configLock.Unlock()
+	s.logger = logger
+}
@@ -0,0 +1,63 @@
+package t1k
+
+import "time"
+
+// Metrics receives instrumentation events from Server so operators can
+// graph pool saturation, per-call latency, heartbeat failures and
+// socket-factory errors. Implementations must be safe for concurrent use.
+// See the promadapter subpackage for a Prometheus-backed implementation.
+type Metrics interface {
+	// IncDetections counts a completed Detect* call, labeled by method
+	// name (e.g. "DetectRequest", "DetectHttpRequest").
+	IncDetections(method string)
+	// ObserveLatency records how long a Detect* call took.
+	ObserveLatency(method string, d time.Duration)
+	// SetPoolInUse reports the number of connections currently checked
+	// out of the pool.
+	SetPoolInUse(n int)
+	// SetPoolIdle reports the number of idle connections held by the
+	// pool.
+	SetPoolIdle(n int)
+	// IncSocketError counts a socket-factory dial failure.
+	IncSocketError()
+	// IncReconnect counts a successful reconnect of a failing connection.
+	IncReconnect()
+}
+
+// noopMetrics is the zero-configuration default: every call is a no-op.
+type noopMetrics struct{}
+
+func (noopMetrics) IncDetections(string)                 {}
+func (noopMetrics) ObserveLatency(string, time.Duration) {}
+func (noopMetrics) SetPoolInUse(int)                     {}
+func (noopMetrics) SetPoolIdle(int)                      {}
+func (noopMetrics) IncSocketError()                      {}
+func (noopMetrics) IncReconnect()                        {}
+
+// UpdateMetrics swaps the Metrics sink Server reports to, e.g. to plug in
+// a Prometheus adapter.
+func (s *Server) UpdateMetrics(m Metrics) {
+	s.configLock.Lock()
+	defer s.configLock.Unlock()
+	s.metrics = m
+}
+
+// InUse reports the number of connections currently checked out of the
+// pool, e.g. for a Balancer that favors the least-loaded backend.
+func (s *Server) InUse() int {
+	n := 0
+	s.socks.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// reportPoolGauges refreshes the in-use/idle pool gauges. in-use is
+// derived from the number of sockets this Server currently has checked
+// out, which it already tracks for PutConn's benefit.
+func (s *Server) reportPoolGauges() {
+	m := s.currentMetrics()
+	m.SetPoolInUse(s.InUse())
+	m.SetPoolIdle(s.currentPool().Len())
+}
@@ -0,0 +1,204 @@
+// Package pool provides a generic net.Conn pooling abstraction so that
+// t1k.Server is not tied to a single pooling strategy.
+package pool
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// ErrPoolClosed is returned by Get/Put once the pool has been closed.
+var ErrPoolClosed = errors.New("pool: pool is closed")
+
+// Factory dials a new underlying connection on demand.
+type Factory func() (net.Conn, error)
+
+// Pool is a generic, reusable net.Conn pool. Implementations must be safe
+// for concurrent use.
+type Pool interface {
+	// Get returns an idle connection, dialing a new one via the factory
+	// if the pool holds none idle. The number of connections dialed this
+	// way is not bounded by the pool's capacity.
+	Get() (net.Conn, error)
+	// Put returns a connection to the pool. Implementations may close the
+	// connection instead of pooling it, e.g. when the pool is full or
+	// closed.
+	Put(net.Conn) error
+	// Close closes the pool and all idle connections it holds. Connections
+	// already checked out are closed as they are returned.
+	Close()
+	// Len reports the number of idle connections currently held by the
+	// pool.
+	Len() int
+}
+
+// FactorySwapper is an optional capability a Pool implementation can
+// support, letting a caller swap the dial factory of a live pool instead
+// of rebuilding it. channelPool implements it.
+type FactorySwapper interface {
+	SwapFactory(Factory)
+}
+
+// channelPool is a Pool backed by a buffered channel, modeled on
+// fatih/pool as used by rqlite's tcp/pool: it holds an initial and a max
+// capacity, dials the initial size eagerly in New and more on demand in
+// Get, and on Put closes the connection instead of blocking when the
+// channel is full.
+type channelPool struct {
+	mu      sync.RWMutex
+	conns   chan net.Conn
+	factory Factory
+}
+
+// New creates a channel-backed Pool with initialCap idle connections
+// dialed eagerly via factory, and room for up to maxCap idle connections
+// in total.
+func New(initialCap, maxCap int, factory Factory) (Pool, error) {
+	if initialCap < 0 || maxCap <= 0 || initialCap > maxCap {
+		return nil, errors.New("pool: invalid capacity settings")
+	}
+
+	p := &channelPool{
+		conns:   make(chan net.Conn, maxCap),
+		factory: factory,
+	}
+
+	for i := 0; i < initialCap; i++ {
+		conn, err := factory()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.conns <- conn
+	}
+
+	return p, nil
+}
+
+// SwapFactory replaces the factory used to dial new connections once the
+// pool is empty. Idle connections already in the pool are unaffected.
+func (p *channelPool) SwapFactory(factory Factory) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.factory = factory
+}
+
+func (p *channelPool) getConnsAndFactory() (chan net.Conn, Factory) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.conns, p.factory
+}
+
+func (p *channelPool) Get() (net.Conn, error) {
+	conns, factory := p.getConnsAndFactory()
+	if conns == nil {
+		return nil, ErrPoolClosed
+	}
+
+	select {
+	case conn, ok := <-conns:
+		if !ok {
+			return nil, ErrPoolClosed
+		}
+		return wrapConn(conn, p), nil
+	default:
+		conn, err := factory()
+		if err != nil {
+			return nil, err
+		}
+		return wrapConn(conn, p), nil
+	}
+}
+
+func (p *channelPool) Put(conn net.Conn) error {
+	if conn == nil {
+		return errors.New("pool: nil connection")
+	}
+
+	// Get always wraps whatever it dequeues in a *pooledConn; unwrap here
+	// so the channel only ever holds raw connections, otherwise each
+	// Get/Put cycle on the same socket would nest another pooledConn
+	// layer without bound.
+	if pc, ok := conn.(*pooledConn); ok {
+		conn = pc.Conn
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.conns == nil {
+		return conn.Close()
+	}
+
+	select {
+	case p.conns <- conn:
+		return nil
+	default:
+		// pool is at capacity, discard this connection
+		return conn.Close()
+	}
+}
+
+func (p *channelPool) Close() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = nil
+	p.factory = nil
+	p.mu.Unlock()
+
+	if conns == nil {
+		return
+	}
+
+	close(conns)
+	for conn := range conns {
+		conn.Close()
+	}
+}
+
+func (p *channelPool) Len() int {
+	conns, _ := p.getConnsAndFactory()
+	return len(conns)
+}
+
+// pooledConn wraps a net.Conn so that Close returns it to the pool instead
+// of closing the underlying socket. If the pool is closed, or marked the
+// connection as failing, Close falls through to a real close.
+type pooledConn struct {
+	net.Conn
+	pool    *channelPool
+	mu      sync.Mutex
+	failing bool
+	closed  bool
+}
+
+func wrapConn(conn net.Conn, p *channelPool) *pooledConn {
+	return &pooledConn{Conn: conn, pool: p}
+}
+
+// MarkFailing flags the connection as unhealthy so that the next Close
+// discards it instead of returning it to the pool.
+func MarkFailing(conn net.Conn) {
+	if pc, ok := conn.(*pooledConn); ok {
+		pc.mu.Lock()
+		pc.failing = true
+		pc.mu.Unlock()
+	}
+}
+
+func (c *pooledConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	failing := c.failing
+	c.mu.Unlock()
+
+	if failing {
+		return c.Conn.Close()
+	}
+	return c.pool.Put(c.Conn)
+}
@@ -0,0 +1,79 @@
+// Package promadapter adapts t1k.Metrics to Prometheus collectors, so
+// callers can register t1k pool/detection instrumentation with their own
+// registry without this module depending on the Prometheus client
+// directly.
+package promadapter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements t1k.Metrics on top of a set of Prometheus collectors
+// registered under the given namespace.
+type Metrics struct {
+	detections  *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+	poolInUse   prometheus.Gauge
+	poolIdle    prometheus.Gauge
+	socketError prometheus.Counter
+	reconnect   prometheus.Counter
+}
+
+// New builds a Metrics and registers its collectors with reg.
+func New(reg prometheus.Registerer, namespace string) (*Metrics, error) {
+	m := &Metrics{
+		detections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "detections_total",
+			Help:      "Number of completed Detect* calls, by method.",
+		}, []string{"method"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "detection_latency_seconds",
+			Help:      "Detect* call latency, by method.",
+		}, []string{"method"}),
+		poolInUse: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pool_in_use_connections",
+			Help:      "Number of connections currently checked out of the pool.",
+		}),
+		poolIdle: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pool_idle_connections",
+			Help:      "Number of idle connections held by the pool.",
+		}),
+		socketError: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "socket_errors_total",
+			Help:      "Number of socket-factory dial failures.",
+		}),
+		reconnect: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconnects_total",
+			Help:      "Number of successful reconnects of a failing connection.",
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{m.detections, m.latency, m.poolInUse, m.poolIdle, m.socketError, m.reconnect} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) IncDetections(method string) {
+	m.detections.WithLabelValues(method).Inc()
+}
+
+func (m *Metrics) ObserveLatency(method string, d time.Duration) {
+	m.latency.WithLabelValues(method).Observe(d.Seconds())
+}
+
+func (m *Metrics) SetPoolInUse(n int) { m.poolInUse.Set(float64(n)) }
+func (m *Metrics) SetPoolIdle(n int)  { m.poolIdle.Set(float64(n)) }
+func (m *Metrics) IncSocketError()    { m.socketError.Inc() }
+func (m *Metrics) IncReconnect()      { m.reconnect.Inc() }
@@ -1,6 +1,8 @@
 package t1k
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net"
 	"net/http"
@@ -11,8 +13,7 @@ import (
 	"time"
 
 	"github.com/chaitin/t1k-go/detection"
-
-	"github.com/chaitin/t1k-go/misc"
+	"github.com/chaitin/t1k-go/pool"
 )
 
 const (
@@ -20,21 +21,60 @@ const (
 	HEARTBEAT_INTERVAL = 20
 )
 
+// ErrServerClosed is returned by GetConn (and every Detect* call built on
+// it) once Close or Shutdown has been called.
+var ErrServerClosed = errors.New("t1k: server closed")
+
 type Server struct {
 	socketFactory   func() (net.Conn, error)
-	poolCh          chan *conn
+	pool            pool.Pool
 	poolSize        int64
-	count           int64
 	closeCh         chan struct{}
-	logger          *log.Logger
+	closeOnce       sync.Once
+	closed          int32 // atomic bool, set once Close/Shutdown begins
+	heartbeatDone   chan struct{}
+	inflight        sync.WaitGroup
+	logger          Logger
+	metrics         Metrics
 	SocketErrorHook func(error)
 
-	cntlock    sync.Mutex
+	// socks tracks the raw net.Conn backing each checked-out *conn, so
+	// PutConn can hand it back to pool without conn needing to expose its
+	// socket.
+	socks sync.Map // map[*conn]net.Conn
+
+	// tlsAddr/tlsPoolSize/tlsTimeout remember how a TLS-backed Server was
+	// built so ReloadTLSConfig can rebuild its factory against a new
+	// tls.Config without losing the original dial timeout.
+	tlsAddr     string
+	tlsPoolSize int
+	tlsTimeout  time.Duration
+
 	configLock sync.RWMutex
 
 	healthCheck *HealthCheckService
 }
 
+func (s *Server) isClosed() bool {
+	return atomic.LoadInt32(&s.closed) != 0
+}
+
+// currentPool reads s.pool under configLock, since ReloadTLSConfig can
+// swap it concurrently with live traffic.
+func (s *Server) currentPool() pool.Pool {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.pool
+}
+
+// currentMetrics reads s.metrics under configLock, since UpdateMetrics can
+// swap it concurrently with live traffic.
+func (s *Server) currentMetrics() Metrics {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.metrics
+}
+
 func (s *Server) UpdateSockErrorHandler(errorHandler func(error)) {
 	s.configLock.Lock()
 	defer s.configLock.Unlock()
@@ -46,6 +86,9 @@ func (s *Server) UpdateSockFactory(socketFactory func() (net.Conn, error)) {
 	s.configLock.Lock()
 	defer s.configLock.Unlock()
 	s.socketFactory = socketFactory
+	if swapper, ok := s.pool.(pool.FactorySwapper); ok {
+		swapper.SwapFactory(pool.Factory(s.CallSockFactory))
+	}
 }
 
 // refactor by YF-Networks's yeyunxi
@@ -64,66 +107,96 @@ func (s *Server) callSockFactory() (net.Conn, error) {
 	return conn, err
 }
 
-func (s *Server) newConn() error {
-	sock, err := s.CallSockFactory()
+// newConn dials (or borrows from the pool) a single socket and wraps it
+// into a *conn ready for use. Every *conn it returns is counted as
+// in-flight until PutConn is called with it, so Shutdown can wait for it.
+func (s *Server) newConn() (*conn, error) {
+	if s.isClosed() {
+		return nil, ErrServerClosed
+	}
+
+	sock, err := s.currentPool().Get()
 	if err != nil {
+		s.currentMetrics().IncSocketError()
+		return nil, err
+	}
+	c := makeConn(sock, s)
+	s.inflight.Add(1)
+	s.socks.Store(c, sock)
+	s.reportPoolGauges()
+	return c, nil
+}
+
+// reconnectIfFailing reconnects c if it was handed back marked failing,
+// returning it to the pool (and discarding it) if the reconnect itself
+// fails. Every path that checks out a *conn — GetConn, getConnContext, and
+// broadcastHeartbeat — must run it, or callers can be handed a stale
+// socket with leftover bytes on the wire.
+func (s *Server) reconnectIfFailing(c *conn) error {
+	if !c.failing {
+		return nil
+	}
+	if err := c.tryReconnIfFailed(); err != nil {
+		s.PutConn(c)
 		return err
 	}
-	s.count += 1
-	s.poolCh <- makeConn(sock, s)
+	s.currentMetrics().IncReconnect()
 	return nil
 }
 
 func (s *Server) GetConn() (*conn, error) {
-	var err error
-
-	if atomic.LoadInt64(&s.count) < s.poolSize {
-		s.cntlock.Lock()
-		if s.count < s.poolSize {
-			for i := int64(0); i < (s.poolSize - s.count); i++ {
-				err = s.newConn()
-				if err != nil {
-					break
-				}
-			}
-		}
-		s.cntlock.Unlock()
-		if err != nil {
-			return nil, err
-		}
+	c, err := s.newConn()
+	if err != nil {
+		return nil, err
 	}
 
-	c := <-s.poolCh
-	if c.failing {
-		err = c.tryReconnIfFailed()
-		if err != nil {
-			s.poolCh <- c
-			return nil, err
-		}
+	if err := s.reconnectIfFailing(c); err != nil {
+		return nil, err
 	}
 
 	return c, nil
 }
 
 func (s *Server) PutConn(c *conn) {
-	s.poolCh <- c
+	sock, ok := s.socks.LoadAndDelete(c)
+	if !ok {
+		return
+	}
+	if c.failing {
+		sock.(net.Conn).Close()
+	} else {
+		s.currentPool().Put(sock.(net.Conn))
+	}
+	s.reportPoolGauges()
+	s.inflight.Done()
 }
 
+// broadcastHeartbeat heartbeats every connection currently idle in the
+// pool. It never dials new sockets on purpose, but because pool.Pool only
+// exposes Len as a hint, a concurrent checkout can cause an iteration to
+// dial a fresh connection instead of reusing an idle one; that connection
+// is heartbeated and returned just the same, so this is harmless.
 func (s *Server) broadcastHeartbeat() {
-	for {
-		select {
-		case c := <-s.poolCh:
-			if !c.failing {
-				c.Heartbeat()
-			}
-			s.PutConn(c)
-		default:
+	n := s.currentPool().Len()
+	for i := 0; i < n; i++ {
+		c, err := s.newConn()
+		if err != nil {
 			return
 		}
+		if err := s.reconnectIfFailing(c); err != nil {
+			continue
+		}
+		c.Heartbeat()
+		s.PutConn(c)
 	}
 }
 
+// runHeartbeatCo runs until closeCh is closed, then closes heartbeatDone so
+// Shutdown can wait for it to have actually stopped before tearing down
+// the pool underneath it.
 func (s *Server) runHeartbeatCo() {
+	defer close(s.heartbeatDone)
+
 	interval := HEARTBEAT_INTERVAL
 	intervalRaw := os.Getenv("T1K_HEARTBEAT_INTERVAL")
 	if intervalRaw != "" {
@@ -136,6 +209,7 @@ func (s *Server) runHeartbeatCo() {
 		timer := time.NewTimer(time.Duration(interval) * time.Second)
 		select {
 		case <-s.closeCh:
+			timer.Stop()
 			return
 		case <-timer.C:
 		}
@@ -158,25 +232,51 @@ func (s *Server) HealthCheckStats() HealthCheckStats {
 	return stats
 }
 
-func NewFromSocketFactoryWithPoolSize(socketFactory func() (net.Conn, error), poolSize int) (*Server, error) {
-	ret := &Server{
-		socketFactory: socketFactory,
-		poolCh:        make(chan *conn, poolSize),
-		poolSize:      int64(poolSize),
-		closeCh:       make(chan struct{}),
-		logger:        log.New(os.Stdout, "snserver", log.LstdFlags),
-		cntlock:       sync.Mutex{},
-		configLock:    sync.RWMutex{},
-	}
+// initCommon finishes constructing a Server around an already-built pool,
+// shared by every constructor below.
+func (s *Server) initCommon(p pool.Pool, poolSize int) error {
+	s.pool = p
+	s.poolSize = int64(poolSize)
+	s.closeCh = make(chan struct{})
+	s.heartbeatDone = make(chan struct{})
+	s.logger = newStdLogger(log.New(os.Stdout, "snserver", log.LstdFlags))
+	s.metrics = noopMetrics{}
 
 	healthCheck, err := NewHealthCheckService()
 	if err != nil {
+		return err
+	}
+	s.healthCheck = healthCheck
+
+	go s.runHeartbeatCo()
+	go s.healthCheck.Run()
+	return nil
+}
+
+// NewFromPool builds a Server on top of a caller-supplied pool.Pool, e.g. a
+// TLS-only pool or one scoped to a single endpoint of a larger deployment.
+func NewFromPool(p pool.Pool, poolSize int) (*Server, error) {
+	ret := &Server{}
+	if err := ret.initCommon(p, poolSize); err != nil {
 		return nil, err
 	}
-	ret.healthCheck = healthCheck
+	return ret, nil
+}
+
+// NewFromSocketFactoryWithPoolSize dials through socketFactory, routed via
+// CallSockFactory so SocketErrorHook fires on dial failures and
+// UpdateSockFactory can swap the live dial factory later.
+func NewFromSocketFactoryWithPoolSize(socketFactory func() (net.Conn, error), poolSize int) (*Server, error) {
+	ret := &Server{socketFactory: socketFactory}
 
-	go ret.runHeartbeatCo()
-	go ret.healthCheck.Run()
+	p, err := pool.New(poolSize, poolSize, pool.Factory(ret.CallSockFactory))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ret.initCommon(p, poolSize); err != nil {
+		return nil, err
+	}
 	return ret, nil
 }
 
@@ -214,54 +314,68 @@ func (s *Server) DetectRequestInCtx(dc *detection.DetectionContext) (*detection.
 }
 
 func (s *Server) DetectResponseInCtx(dc *detection.DetectionContext) (*detection.Result, error) {
-	c, err := s.GetConn()
-	if err != nil {
-		return nil, misc.ErrorWrap(err, "")
-	}
-	defer s.PutConn(c)
-	return c.DetectResponseInCtx(dc)
+	return s.DetectResponseContext(context.Background(), dc)
 }
 
 func (s *Server) Detect(dc *detection.DetectionContext) (*detection.Result, *detection.Result, error) {
-	c, err := s.GetConn()
-	if err != nil {
-		return nil, nil, misc.ErrorWrap(err, "")
-	}
-
-	reqResult, rspResult, err := c.Detect(dc)
-	if err == nil {
-		s.PutConn(c)
-	}
-	return reqResult, rspResult, err
+	return s.DetectContext(context.Background(), dc)
 }
 
 func (s *Server) DetectHttpRequest(req *http.Request) (*detection.Result, error) {
-	c, err := s.GetConn()
-	if err != nil {
-		return nil, err
-	}
-	defer s.PutConn(c)
-	return c.DetectHttpRequest(req)
+	return s.DetectHttpRequestContext(context.Background(), req)
 }
 
 func (s *Server) DetectRequest(req detection.Request) (*detection.Result, error) {
-	c, err := s.GetConn()
-	if err != nil {
-		return nil, err
-	}
-	defer s.PutConn(c)
-	return c.DetectRequest(req)
+	return s.DetectRequestContext(context.Background(), req)
 }
 
-// blocks until all pending detection is completed
+// Close closes the server immediately: it stops the heartbeat and health
+// check loops, closes every idle pooled connection, and makes every
+// subsequent Detect* call return ErrServerClosed. It does not wait for
+// in-flight detections to return their connections; use Shutdown for a
+// bounded, graceful drain instead.
 func (s *Server) Close() {
-	close(s.closeCh)
-	for i := int64(0); i < s.count; i++ {
-		c, err := s.GetConn()
-		if err != nil {
-			return
+	atomic.StoreInt32(&s.closed, 1)
+	s.closeOnce.Do(func() { close(s.closeCh) })
+	s.currentPool().Close()
+	s.healthCheck.Close()
+}
+
+// Shutdown marks the server closed so new Detect* calls fail fast with
+// ErrServerClosed, then waits for in-flight calls to return their
+// connections (or for ctx to expire, whichever comes first). It then waits
+// for the heartbeat loop to stop, also bounded by ctx, before closing the
+// pool and the health check service. If ctx expires first, the pool is
+// closed without waiting any further and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.closed, 1)
+	s.closeOnce.Do(func() { close(s.closeCh) })
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// Bound the wait for the heartbeat loop by ctx too: broadcastHeartbeat
+	// dials through the socket factory, which can block far longer than
+	// ctx on a stalled host for a Server built without a dial timeout.
+	select {
+	case <-s.heartbeatDone:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
 		}
-		c.Close()
 	}
+
+	s.currentPool().Close()
 	s.healthCheck.Close()
+	return err
 }
@@ -0,0 +1,113 @@
+package t1k
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"github.com/chaitin/t1k-go/pool"
+)
+
+// ErrNotTLS is returned by ReloadTLSConfig when called on a Server that was
+// not built with a TLS socket factory.
+var ErrNotTLS = errors.New("t1k: server was not built with a TLS socket factory")
+
+func tlsDialer(addr string, cfg *tls.Config, timeout time.Duration) func() (net.Conn, error) {
+	cfg = cfg.Clone()
+	if cfg.ServerName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			cfg.ServerName = host
+		} else {
+			cfg.ServerName = addr
+		}
+	}
+
+	return func() (net.Conn, error) {
+		return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, cfg)
+	}
+}
+
+// NewTLSWithPoolSize builds a Server that dials addr over TLS using cfg.
+// ServerName is derived from addr when cfg does not already set one; the
+// peer certificate chain is validated per cfg (the zero value verifies
+// against the system root store).
+func NewTLSWithPoolSize(addr string, cfg *tls.Config, poolSize int, timeout time.Duration) (*Server, error) {
+	factory := tlsDialer(addr, cfg, timeout)
+
+	p, err := pool.New(poolSize, poolSize, pool.Factory(factory))
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := NewFromPool(p, poolSize)
+	if err != nil {
+		return nil, err
+	}
+	ret.socketFactory = factory
+	ret.tlsAddr = addr
+	ret.tlsPoolSize = poolSize
+	ret.tlsTimeout = timeout
+	return ret, nil
+}
+
+// NewMTLS builds a Server that authenticates to addr with the client
+// certificate in certFile/keyFile, and verifies the peer against the CA
+// bundle in caFile.
+func NewMTLS(addr, caFile, certFile, keyFile string, poolSize int, timeout time.Duration) (*Server, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("t1k: failed to parse CA certificate")
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      caPool,
+	}
+
+	return NewTLSWithPoolSize(addr, cfg, poolSize, timeout)
+}
+
+// ReloadTLSConfig swaps in a new TLS configuration for a Server built via
+// NewTLSWithPoolSize/NewMTLS, so certificates can be rotated without
+// restarting the process. It closes every idle pooled connection and
+// marks in-flight ones failing so GetConn reconnects them under the new
+// config instead of reusing a socket negotiated under the old one.
+func (s *Server) ReloadTLSConfig(cfg *tls.Config) error {
+	s.configLock.Lock()
+	defer s.configLock.Unlock()
+
+	if s.tlsAddr == "" {
+		return ErrNotTLS
+	}
+
+	factory := tlsDialer(s.tlsAddr, cfg, s.tlsTimeout)
+
+	newPool, err := pool.New(0, s.tlsPoolSize, pool.Factory(factory))
+	if err != nil {
+		return err
+	}
+
+	oldPool := s.pool
+	s.pool = newPool
+	s.socketFactory = factory
+	oldPool.Close()
+
+	s.socks.Range(func(key, _ interface{}) bool {
+		key.(*conn).failing = true
+		return true
+	})
+
+	return nil
+}